@@ -0,0 +1,98 @@
+package jwtware
+
+import (
+	"math/rand"
+	"time"
+)
+
+// KeyRefreshBackoff configures the retry delay used when a JWKs refresh
+// fails, so that an outage at the IdP doesn't turn into a thundering herd of
+// retries. The delay starts at Initial and is multiplied by Multiplier after
+// each consecutive failure, capped at Max, and reset to zero as soon as a
+// refresh succeeds.
+type KeyRefreshBackoff struct {
+	// Initial is the delay before the first retry after a failure.
+	Initial time.Duration
+
+	// Max caps the delay between retries, however many failures in a row
+	// there have been.
+	Max time.Duration
+
+	// Multiplier scales the delay after each consecutive failure. A value
+	// of 2 doubles the delay every time.
+	Multiplier float64
+
+	// Jitter is the fraction of the computed delay to randomize, e.g. 0.1
+	// to vary the delay by +/-10%. Zero disables jitter.
+	Jitter float64
+}
+
+// refreshAndHandleError performs a refresh, reports any error to the
+// configured refreshErrorHandler, and arms a backoff retry if
+// KeyRefreshBackoff is configured. On success it resets the backoff so the
+// next failure starts from Initial again.
+func (j *keySet) refreshAndHandleError() {
+	if err := j.refresh(); err != nil {
+		if j.refreshErrorHandler != nil {
+			j.refreshErrorHandler(err)
+		}
+		j.scheduleBackoffRetry()
+		return
+	}
+
+	j.resetBackoff()
+}
+
+// resetBackoff clears the backoff delay after a successful refresh.
+func (j *keySet) resetBackoff() {
+	j.backoffMux.Lock()
+	j.backoffDelay = 0
+	j.backoffMux.Unlock()
+}
+
+// scheduleBackoffRetry advances the backoff delay and, if KeyRefreshBackoff
+// is configured, launches a goroutine that queues a refresh request once the
+// delay elapses.
+func (j *keySet) scheduleBackoffRetry() {
+	backoff := j.config.KeyRefreshBackoff
+	if backoff == nil {
+		return
+	}
+
+	j.backoffMux.Lock()
+	if j.backoffDelay <= 0 {
+		j.backoffDelay = backoff.Initial
+	} else {
+		j.backoffDelay = time.Duration(float64(j.backoffDelay) * backoff.Multiplier)
+	}
+	if backoff.Max > 0 && j.backoffDelay > backoff.Max {
+		j.backoffDelay = backoff.Max
+	}
+	delay := withJitter(j.backoffDelay, backoff.Jitter)
+	j.backoffMux.Unlock()
+
+	go func() {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		select {
+		case <-j.ctx.Done():
+		case j.refreshRequests <- &refreshRequest{release: func() {}, done: make(chan struct{})}:
+		default: // A refresh is already queued; no need for another.
+		}
+	}()
+}
+
+// withJitter randomizes d by up to +/-fraction, leaving it unchanged if
+// fraction is zero or negative.
+func withJitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+
+	delta := float64(d) * fraction
+	return d - time.Duration(delta) + time.Duration(rand.Float64()*2*delta)
+}
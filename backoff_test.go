@@ -0,0 +1,108 @@
+package jwtware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestBackoffRetriesThenRecovers verifies that a background refresh failure
+// is retried via KeyRefreshBackoff (instead of only at the next scheduled
+// KeyRefreshInterval), reports the error to KeyRefreshErrorHandler, and that
+// the key set recovers once the IdP starts responding again.
+func TestBackoffRetriesThenRecovers(t *testing.T) {
+	const kid = "kid-1"
+	otherKeysJWKS := []byte(`{"keys":[]}`)
+	targetKeyJWKS := []byte(`{"keys":[{"kid":"` + kid + `","kty":"oct","k":"c2VjcmV0"}]}`)
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		switch {
+		case n == 1:
+			// The initial synchronous fetch succeeds, but without the kid
+			// we're after, so the first getKey call below has to trigger a
+			// refresh.
+			_, _ = w.Write(otherKeysJWKS)
+		case n == 2 || n == 3:
+			// The IdP is briefly down; these refreshes must be retried via
+			// backoff rather than given up on.
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write(targetKeyJWKS)
+		}
+	}))
+	defer srv.Close()
+
+	var failures int32
+	config := Config{
+		KeySetUrl: srv.URL,
+		KeyRefreshBackoff: &KeyRefreshBackoff{
+			Initial:    5 * time.Millisecond,
+			Max:        20 * time.Millisecond,
+			Multiplier: 2,
+		},
+		KeyRefreshErrorHandler: func(err error) {
+			atomic.AddInt32(&failures, 1)
+		},
+	}
+
+	ks, err := getKeySet(config)
+	if err != nil {
+		t.Fatalf("getKeySet: %v", err)
+	}
+	defer ks.stopRefreshing()
+
+	// Ask for the kid once. Its refresh fails, and from then on only the
+	// KeyRefreshBackoff retries fetch the JWKs again, so the loop below only
+	// watches the key set instead of triggering refreshes of its own.
+	_, _ = ks.getKey(kid)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ks.mux.RLock()
+		_, ok := ks.keys[kid]
+		ks.mux.RUnlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("key never became available after backoff retries (failures observed: %d)", atomic.LoadInt32(&failures))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&failures) == 0 {
+		t.Fatal("expected at least one refresh failure to have been reported to KeyRefreshErrorHandler before recovery")
+	}
+
+	ks.backoffMux.Lock()
+	delay := ks.backoffDelay
+	ks.backoffMux.Unlock()
+	if delay != 0 {
+		t.Fatalf("expected backoffDelay to be reset to 0 after a successful refresh, got %v", delay)
+	}
+}
+
+// TestWithJitter verifies that the jitter applied to a backoff delay stays
+// within the requested fraction of the original delay, and that it's a
+// no-op when fraction is zero.
+func TestWithJitter(t *testing.T) {
+	const delay = 100 * time.Millisecond
+
+	if got := withJitter(delay, 0); got != delay {
+		t.Fatalf("expected no jitter when fraction is 0, got %v", got)
+	}
+
+	const fraction = 0.5
+	min := delay - time.Duration(float64(delay)*fraction)
+	max := delay + time.Duration(float64(delay)*fraction)
+	for i := 0; i < 100; i++ {
+		got := withJitter(delay, fraction)
+		if got < min || got > max {
+			t.Fatalf("withJitter(%v, %v) = %v, want within [%v, %v]", delay, fraction, got, min, max)
+		}
+	}
+}
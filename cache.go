@@ -0,0 +1,143 @@
+package jwtware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrKeyCacheMiss indicates that a KeyCache has no entry for the requested
+// URL.
+var ErrKeyCacheMiss = errors.New("no cached JWKs for this URL")
+
+// KeyCache is a pluggable store for the raw JWKs JSON document, written to by
+// refresh after each successful fetch and read by getKeySet only when its
+// initial HTTP fetch fails. It lets a service boot with a previously-seen
+// JWKs when the IdP is momentarily unreachable. getKeySet always tries the
+// network first, so a cached copy does not save the fetch on start.
+type KeyCache interface {
+	// Load returns the cached JWKs for url and the time it was fetched. It
+	// should return an error if there is no cached entry.
+	Load(ctx context.Context, url string) (jwks []byte, fetched time.Time, err error)
+
+	// Store saves the JWKs fetched for url at the given time.
+	Store(ctx context.Context, url string, jwks []byte, fetched time.Time) error
+}
+
+// memoryCache is the default KeyCache, kept only for the lifetime of the
+// process.
+type memoryCache struct {
+	mux     sync.RWMutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	jwks    []byte
+	fetched time.Time
+}
+
+// newMemoryCache creates an empty in-memory KeyCache.
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		entries: make(map[string]memoryCacheEntry),
+	}
+}
+
+func (m *memoryCache) Load(_ context.Context, url string) ([]byte, time.Time, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	entry, ok := m.entries[url]
+	if !ok {
+		return nil, time.Time{}, ErrKeyCacheMiss
+	}
+
+	return entry.jwks, entry.fetched, nil
+}
+
+func (m *memoryCache) Store(_ context.Context, url string, jwks []byte, fetched time.Time) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.entries[url] = memoryCacheEntry{jwks: jwks, fetched: fetched}
+
+	return nil
+}
+
+// filesystemCache is a KeyCache that persists each URL's JWKs as a file
+// under a base directory, so cached keys survive across process restarts.
+type filesystemCache struct {
+	dir string
+}
+
+// NewFilesystemKeyCache creates a KeyCache that persists JWKs documents as
+// files under dir, one per URL. dir is created if it doesn't already exist.
+func NewFilesystemKeyCache(dir string) (KeyCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &filesystemCache{dir: dir}, nil
+}
+
+func (f *filesystemCache) Load(_ context.Context, url string) ([]byte, time.Time, error) {
+	path := f.path(url)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, ErrKeyCacheMiss
+	}
+
+	jwks, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return jwks, info.ModTime(), nil
+}
+
+func (f *filesystemCache) Store(_ context.Context, url string, jwks []byte, fetched time.Time) error {
+	path := f.path(url)
+
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		return err
+	}
+
+	return os.Chtimes(path, fetched, fetched)
+}
+
+// path turns a URL into a filesystem-safe path under the cache directory.
+func (f *filesystemCache) path(url string) string {
+	return filepath.Join(f.dir, cacheFileName(url))
+}
+
+// cacheFileName turns a URL into a stable, filesystem-safe file name.
+func cacheFileName(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:]) + ".jwks.json"
+}
+
+// loadFromCache consults j.config.KeyCache for a previously-fetched JWKs. It
+// returns ErrKeyCacheMiss if no cache is configured, there's no entry for
+// this URL, or the entry is older than KeyCacheMaxStaleness.
+func (j *keySet) loadFromCache() (*keySet, error) {
+	if j.config.KeyCache == nil {
+		return nil, ErrKeyCacheMiss
+	}
+
+	jwksBytes, fetched, err := j.config.KeyCache.Load(context.Background(), j.config.KeySetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if max := j.config.KeyCacheMaxStaleness; max != nil && time.Since(fetched) > *max {
+		return nil, ErrKeyCacheMiss
+	}
+
+	return parseKeySet(jwksBytes)
+}
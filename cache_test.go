@@ -0,0 +1,90 @@
+package jwtware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestGetKeySetCacheFallback verifies that when the initial JWKs fetch
+// fails, getKeySet falls back to a cached copy instead of failing outright,
+// and that it still starts a background refresh goroutine so the key set
+// can recover once the IdP is reachable again.
+func TestGetKeySetCacheFallback(t *testing.T) {
+	const kid = "cached-kid"
+	cachedJWKS := []byte(`{"keys":[{"kid":"` + kid + `","kty":"oct","k":"Y2FjaGVkLXNlY3JldA"}]}`)
+
+	// Once up, the origin serves a key that the cached copy doesn't have.
+	const freshKid = "fresh-kid"
+	freshJWKS := []byte(`{"keys":[{"kid":"` + freshKid + `","kty":"oct","k":"ZnJlc2gtc2VjcmV0"}]}`)
+
+	var up int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(freshJWKS)
+	}))
+	defer srv.Close()
+
+	cache := newMemoryCache()
+	if err := cache.Store(context.Background(), srv.URL, cachedJWKS, time.Now()); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	config := Config{
+		KeySetUrl: srv.URL,
+		KeyCache:  cache,
+	}
+
+	ks, err := getKeySet(config)
+	if err != nil {
+		t.Fatalf("getKeySet should have fallen back to the cache instead of failing: %v", err)
+	}
+	defer ks.stopRefreshing()
+
+	if _, err := ks.getKey(kid); err != nil {
+		t.Fatalf("expected the cached key to be served while the origin is down: %v", err)
+	}
+
+	if ks.ctx == nil || ks.refreshRequests == nil {
+		t.Fatal("expected a cache fallback to start the background refresh goroutine")
+	}
+
+	// Bring the origin up and confirm the background goroutine eventually
+	// refreshes past the stale cached copy.
+	atomic.StoreInt32(&up, 1)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ks.mux.RLock()
+		_, ok := ks.keys[freshKid]
+		ks.mux.RUnlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh never recovered once the origin came back up")
+		}
+		_, _ = ks.getKey(freshKid)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestKeySetRefreshImpossibleForStaticJSON verifies that a key set built
+// from a static JSON blob (no config, no URL) reports ErrRefreshImpossible
+// rather than panicking.
+func TestKeySetRefreshImpossibleForStaticJSON(t *testing.T) {
+	ks, err := parseKeySet([]byte(`{"keys":[]}`))
+	if err != nil {
+		t.Fatalf("parseKeySet: %v", err)
+	}
+
+	if err := ks.Refresh(context.Background()); err != ErrRefreshImpossible {
+		t.Fatalf("expected ErrRefreshImpossible, got %v", err)
+	}
+}
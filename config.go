@@ -0,0 +1,95 @@
+package jwtware
+
+import "time"
+
+// defaultKeyRefreshMinInterval is used when KeyRefreshMinInterval isn't set,
+// so a misconfigured origin's Cache-Control/Expires headers can't make the
+// middleware hammer the JWKs endpoint.
+const defaultKeyRefreshMinInterval = 15 * time.Minute
+
+// defaultKeyRefreshTimeout is used when KeyRefreshTimeout isn't set.
+const defaultKeyRefreshTimeout = 5 * time.Second
+
+// defaultKeyRefreshUnknownKID is used when KeyRefreshUnknownKID isn't set.
+const defaultKeyRefreshUnknownKID = true
+
+// Config holds the settings for a single JWKs-backed key set.
+type Config struct {
+	// KeySetUrl is the HTTP(S) URL the JWKs is fetched from.
+	KeySetUrl string
+
+	// KeyRefreshInterval is how often to proactively refresh the JWKs in
+	// the background. Nil disables the static interval, leaving refreshes
+	// to be driven by Cache-Control/Expires headers, KeyRefreshUnknownKID,
+	// or manual calls to Refresh.
+	KeyRefreshInterval *time.Duration
+
+	// KeyRefreshRateLimit is the minimum time that must pass between two
+	// refreshes, however many things triggered them.
+	KeyRefreshRateLimit *time.Duration
+
+	// KeyRefreshTimeout bounds a single JWKs HTTP fetch. Defaults to 5
+	// seconds.
+	KeyRefreshTimeout *time.Duration
+
+	// KeyRefreshUnknownKID controls whether an unrecognized kid triggers an
+	// on-demand refresh before giving up with ErrKIDNotFound. Defaults to
+	// true.
+	KeyRefreshUnknownKID *bool
+
+	// KeyRefreshErrorHandler is invoked with any error encountered during a
+	// background refresh.
+	KeyRefreshErrorHandler ErrorHandler
+
+	// KeyRefreshMinInterval floors how soon a Cache-Control/Expires-derived
+	// refresh may be scheduled, so a misconfigured origin can't be polled
+	// aggressively. Defaults to 15 minutes.
+	KeyRefreshMinInterval *time.Duration
+
+	// KeyRefreshMaxInterval caps how far out a Cache-Control/Expires-derived
+	// refresh may be scheduled, for origins that say to cache forever. Nil
+	// means no ceiling.
+	KeyRefreshMaxInterval *time.Duration
+
+	// KeyRefreshBackoff configures the retry delay used when a background
+	// refresh fails. Nil disables backoff: a failed refresh is simply
+	// retried at the next scheduled interval or trigger.
+	KeyRefreshBackoff *KeyRefreshBackoff
+
+	// KeyCache persists the raw JWKs document so a later cold start can use
+	// it if the IdP is momentarily unreachable. Defaults to a new, empty
+	// in-memory cache per key set, which is only read at start and so never
+	// supplies a fallback; set this to a persistent implementation, such as
+	// one backed by the filesystem, for it to survive a restart.
+	KeyCache KeyCache
+
+	// KeyCacheMaxStaleness bounds how old a cached JWKs may be and still be
+	// used as a cold-start fallback. Nil means a cached copy is used
+	// regardless of age.
+	KeyCacheMaxStaleness *time.Duration
+}
+
+// withDefaults returns a copy of config with unset fields given their
+// documented defaults.
+func (config Config) withDefaults() Config {
+	if config.KeyRefreshMinInterval == nil {
+		d := defaultKeyRefreshMinInterval
+		config.KeyRefreshMinInterval = &d
+	}
+
+	if config.KeyRefreshTimeout == nil {
+		d := defaultKeyRefreshTimeout
+		config.KeyRefreshTimeout = &d
+	}
+
+	if config.KeyRefreshUnknownKID == nil {
+		b := defaultKeyRefreshUnknownKID
+		config.KeyRefreshUnknownKID = &b
+	}
+
+	if config.KeyCache == nil {
+		config.KeyCache = newMemoryCache()
+	}
+
+	return config
+}
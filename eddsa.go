@@ -0,0 +1,48 @@
+package jwtware
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+)
+
+const (
+	eddsa = "EdDSA"
+
+	hs256 = "HS256"
+	hs384 = "HS384"
+	hs512 = "HS512"
+)
+
+// getEdDSA returns the ed25519.PublicKey for a JWK with kty=OKP, crv=Ed25519.
+func (j *rawJWK) getEdDSA() (ed25519.PublicKey, error) {
+	if j.X == "" {
+		return nil, fmt.Errorf("%w: %s", ErrMissingAssets, "x")
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: Ed25519 public key has the wrong length", ErrMissingAssets)
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+// getHMAC returns the raw symmetric key bytes for a JWK with kty=oct, used to
+// verify HS256/HS384/HS512 tokens signed with a shared secret.
+//
+// JWKS endpoints are conventionally public and unauthenticated, meant only
+// to publish asymmetric public keys. Publishing an HS*-family secret in one
+// hands it to anyone who can reach the URL, letting them forge tokens, so
+// only use kty=oct entries behind an endpoint you control and trust.
+func (j *rawJWK) getHMAC() ([]byte, error) {
+	if j.SymmetricKey == "" {
+		return nil, fmt.Errorf("%w: %s", ErrMissingAssets, "k")
+	}
+
+	return base64.RawURLEncoding.DecodeString(j.SymmetricKey)
+}
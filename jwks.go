@@ -8,10 +8,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
@@ -33,13 +36,15 @@ type ErrorHandler func(err error)
 
 // rawJWK represents a raw key inside a JWKs.
 type rawJWK struct {
-	Curve       string `json:"crv"`
-	Exponent    string `json:"e"`
-	ID          string `json:"kid"`
-	Modulus     string `json:"n"`
-	X           string `json:"x"`
-	Y           string `json:"y"`
-	precomputed interface{}
+	Curve        string `json:"crv"`
+	Exponent     string `json:"e"`
+	ID           string `json:"kid"`
+	KeyType      string `json:"kty"`
+	Modulus      string `json:"n"`
+	SymmetricKey string `json:"k"`
+	X            string `json:"x"`
+	Y            string `json:"y"`
+	precomputed  interface{}
 }
 
 // rawJWKs represents a JWKs in JSON format.
@@ -56,7 +61,32 @@ type keySet struct {
 	ctx                 context.Context
 	mux                 sync.RWMutex
 	refreshErrorHandler ErrorHandler
-	refreshRequests     chan context.CancelFunc
+	refreshRequests     chan *refreshRequest
+
+	// cacheRefreshAfter, when non-zero, is the duration until the next
+	// refresh as derived from the most recent response's Cache-Control or
+	// Expires headers. It's guarded by mux alongside keys.
+	cacheRefreshAfter time.Duration
+
+	// backoffMux guards backoffDelay, which tracks how long the next retry
+	// after a failed refresh should wait.
+	backoffMux   sync.Mutex
+	backoffDelay time.Duration
+
+	// unknownKIDGroup coalesces concurrent refreshes triggered by an unknown
+	// kid so that only one happens at a time per URL.
+	unknownKIDGroup singleflight.Group
+}
+
+// refreshRequest is sent to the background goroutine via refreshRequests to
+// ask for a refresh. release is called as soon as the requester may proceed
+// with a best-effort result, which under rate limiting happens before the
+// real fetch runs. done is closed once the real fetch (if any) has actually
+// finished, for callers like Refresh's WithBlockUntilComplete that need to
+// know the fetch itself is over, not just that they've been unblocked.
+type refreshRequest struct {
+	release func()
+	done    chan struct{}
 }
 
 // keyFunc is a compatibility function that matches the signature of github.com/dgrijalva/jwt-go's keyFunc function.
@@ -83,6 +113,10 @@ func (j *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
 		return jsonKey.getECDSA()
 	case ps256, ps384, ps512, rs256, rs384, rs512:
 		return jsonKey.getRSA()
+	case eddsa:
+		return jsonKey.getEdDSA()
+	case hs256, hs384, hs512:
+		return jsonKey.getHMAC()
 	default:
 		return nil, fmt.Errorf("%w: %s: feel free to add a feature request or contribute to https://github.com/MicahParks/keyfunc", ErrUnsupportedKeyType, keyAlg)
 	}
@@ -90,9 +124,12 @@ func (j *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
 
 // getKeySet loads the JWKs at the given URL.
 func getKeySet(config Config) (jwks *keySet, err error) {
+	config = config.withDefaults()
+
 	// Create the JWKs.
 	jwks = &keySet{
-		config: &config,
+		config:              &config,
+		refreshErrorHandler: config.KeyRefreshErrorHandler,
 	}
 
 	// Apply some defaults if options were not provided.
@@ -101,21 +138,47 @@ func getKeySet(config Config) (jwks *keySet, err error) {
 	}
 
 	// Get the keys for the JWKs.
+	usedCacheFallback := false
 	if err = jwks.refresh(); err != nil {
-		return nil, err
+		// The network fetch failed. Fall back to a cached copy, if one is
+		// configured and still within the allowed staleness, so the service
+		// can boot even when the IdP is momentarily unreachable.
+		cached, loadErr := jwks.loadFromCache()
+		if loadErr != nil {
+			return nil, err
+		}
+		jwks.keys = cached.keys
+		usedCacheFallback = true
 	}
 
-	// Check to see if a background refresh of the JWKs should happen.
-	if config.KeyRefreshInterval != nil || config.KeyRefreshRateLimit != nil {
+	// Check to see if a background refresh of the JWKs should happen. A
+	// cache fallback always needs one, even with no static interval
+	// configured: it's how the JWKs ever gets past the stale cached copy.
+	// KeyRefreshUnknownKID also needs one: getKey's unknown-kid path queues
+	// its refresh on j.refreshRequests and waits on j.ctx, and it defaults
+	// to true, so most configs reach this point needing the goroutine
+	// regardless of whether an interval or rate limit was set.
+	if config.KeyRefreshInterval != nil || config.KeyRefreshRateLimit != nil || jwks.cacheRefreshAfter != 0 ||
+		usedCacheFallback || (config.KeyRefreshUnknownKID != nil && *config.KeyRefreshUnknownKID) {
 
 		// Attach a context used to end the background goroutine.
 		jwks.ctx, jwks.cancel = context.WithCancel(context.Background())
 
 		// Create a channel that will accept requests to refresh the JWKs.
-		jwks.refreshRequests = make(chan context.CancelFunc, 1)
+		jwks.refreshRequests = make(chan *refreshRequest, 1)
 
 		// Start the background goroutine for data refresh.
 		go jwks.startRefreshing()
+
+		// A cache fallback means the network is currently down; queue an
+		// immediate retry behind the background goroutine's rate limiter
+		// instead of waiting for the next scheduled interval or request.
+		if usedCacheFallback {
+			select {
+			case jwks.refreshRequests <- &refreshRequest{release: func() {}, done: make(chan struct{})}:
+			default:
+			}
+		}
 	}
 
 	return jwks, nil
@@ -153,25 +216,35 @@ func (j *keySet) getKey(kid string) (jsonKey *rawJWK, err error) {
 	// Check if the key was present.
 	if !ok {
 
-		// Check to see if configured to refresh on unknown kid.
-		if *j.config.KeyRefreshUnknownKID {
-
-			// Create a context for refreshing the JWKs.
-			ctx, cancel := context.WithCancel(j.ctx)
-
-			// Refresh the JWKs.
-			select {
-			case <-j.ctx.Done():
-				return
-			case j.refreshRequests <- cancel:
-			default:
+		// Check to see if configured to refresh on unknown kid. A keySet
+		// built from a static JSON blob via parseKeySet has no config (and
+		// so no URL to refresh from), so it always falls straight through
+		// to ErrKIDNotFound.
+		if j.config != nil && j.config.KeyRefreshUnknownKID != nil && *j.config.KeyRefreshUnknownKID {
+
+			// Coalesce concurrent unknown-kid refreshes for this URL into a
+			// single queued refresh request: every caller that arrives while
+			// one is already in flight waits on its result instead of
+			// immediately failing with ErrKIDNotFound.
+			_, _, _ = j.unknownKIDGroup.Do(j.config.KeySetUrl, func() (interface{}, error) {
+				// Create a context that's cancelled as soon as the
+				// background goroutine releases us, which may be before the
+				// real fetch completes if rate limited.
+				ctx, release := context.WithCancel(j.ctx)
+				req := &refreshRequest{release: release, done: make(chan struct{})}
+
+				// Queue the refresh request.
+				select {
+				case <-j.ctx.Done():
+					return nil, j.ctx.Err()
+				case j.refreshRequests <- req:
+				}
 
-				// If the j.refreshRequests channel is full, return the error early.
-				return nil, ErrKIDNotFound
-			}
+				// Wait for the JWKs refresh to be done.
+				<-ctx.Done()
 
-			// Wait for the JWKs refresh to done.
-			<-ctx.Done()
+				return nil, nil
+			})
 
 			// Lock the JWKs for async safe use.
 			j.mux.RLock()
@@ -196,6 +269,7 @@ func (j *keySet) startRefreshing() {
 	var lastRefresh time.Time
 	var queueOnce sync.Once
 	var refreshMux sync.Mutex
+	var pendingDone []chan struct{}
 	if j.config.KeyRefreshRateLimit != nil {
 		lastRefresh = time.Now().Add(-*j.config.KeyRefreshRateLimit)
 	}
@@ -206,9 +280,11 @@ func (j *keySet) startRefreshing() {
 	// Enter an infinite loop that ends when the background ends.
 	for {
 
-		// If there is a refresh interval, create the channel for it.
-		if j.config.KeyRefreshInterval != nil {
-			refreshInterval = time.After(*j.config.KeyRefreshInterval)
+		// Determine the next refresh interval, taking the sooner of the
+		// configured static interval and the one derived from the last
+		// response's cache headers.
+		if interval, ok := j.nextRefreshInterval(); ok {
+			refreshInterval = time.After(interval)
 		}
 
 		// Wait for a refresh to occur or the background to end.
@@ -219,19 +295,23 @@ func (j *keySet) startRefreshing() {
 			select {
 			case <-j.ctx.Done():
 				return
-			case j.refreshRequests <- func() {}:
+			case j.refreshRequests <- &refreshRequest{release: func() {}, done: make(chan struct{})}:
 			default: // If the j.refreshRequests channel is full, don't don't send another request.
 			}
 
 		// Accept refresh requests.
-		case cancel := <-j.refreshRequests:
+		case req := <-j.refreshRequests:
 
 			// Rate limit, if needed.
 			refreshMux.Lock()
 			if j.config.KeyRefreshRateLimit != nil && lastRefresh.Add(*j.config.KeyRefreshRateLimit).After(time.Now()) {
 
 				// Don't make the JWT parsing goroutine wait for the JWKs to refresh.
-				cancel()
+				req.release()
+
+				// This request's real completion is signaled once the
+				// queued goroutine below actually performs the fetch.
+				pendingDone = append(pendingDone, req.done)
 
 				// Only queue a refresh once.
 				queueOnce.Do(func() {
@@ -251,28 +331,32 @@ func (j *keySet) startRefreshing() {
 						// Refresh the JWKs.
 						refreshMux.Lock()
 						defer refreshMux.Unlock()
-						if err := j.refresh(); err != nil && j.refreshErrorHandler != nil {
-							j.refreshErrorHandler(err)
-						}
+						j.refreshAndHandleError()
 
 						// Reset the last time for the refresh to now.
 						lastRefresh = time.Now()
 
+						// The real fetch is now done: wake up everyone who
+						// queued behind it while it was rate limited.
+						for _, done := range pendingDone {
+							close(done)
+						}
+						pendingDone = nil
+
 						// Allow another queue.
 						queueOnce = sync.Once{}
 					}()
 				})
 			} else {
 				// Refresh the JWKs.
-				if err := j.refresh(); err != nil && j.refreshErrorHandler != nil {
-					j.refreshErrorHandler(err)
-				}
+				j.refreshAndHandleError()
 
 				// Reset the last time for the refresh to now.
 				lastRefresh = time.Now()
 
 				// Allow the JWT parsing goroutine to continue with the refreshed JWKs.
-				cancel()
+				req.release()
+				close(req.done)
 			}
 			refreshMux.Unlock()
 
@@ -283,6 +367,28 @@ func (j *keySet) startRefreshing() {
 	}
 }
 
+// nextRefreshInterval returns how long to wait before the next scheduled
+// refresh and whether a scheduled refresh is configured at all. When both a
+// static KeyRefreshInterval and a cache-header-derived interval are known,
+// the sooner of the two is returned.
+func (j *keySet) nextRefreshInterval() (interval time.Duration, ok bool) {
+	if j.config.KeyRefreshInterval != nil {
+		interval = *j.config.KeyRefreshInterval
+		ok = true
+	}
+
+	j.mux.RLock()
+	cacheRefreshAfter := j.cacheRefreshAfter
+	j.mux.RUnlock()
+
+	if cacheRefreshAfter != 0 && (!ok || cacheRefreshAfter < interval) {
+		interval = cacheRefreshAfter
+		ok = true
+	}
+
+	return interval, ok
+}
+
 // refresh does an HTTP GET on the JWKs URL to rebuild the JWKs.
 func (j *keySet) refresh() (err error) {
 	// Create a context for the request.
@@ -320,16 +426,75 @@ func (j *keySet) refresh() (err error) {
 		return err
 	}
 
+	// Figure out when the response says it's safe to refresh again, clamped
+	// to the configured floor and ceiling, if any.
+	cacheRefreshAfter := cacheRefreshDuration(resp.Header, j.config.KeyRefreshMinInterval, j.config.KeyRefreshMaxInterval)
+
 	// Lock the JWKs for async safe usage.
 	j.mux.Lock()
 	defer j.mux.Unlock()
 
 	// Update the keys.
 	j.keys = updated.keys
+	j.cacheRefreshAfter = cacheRefreshAfter
+
+	// Persist the fetched JWKs so a future cold start can use it if the IdP
+	// is unreachable.
+	if j.config.KeyCache != nil {
+		_ = j.config.KeyCache.Store(ctx, j.config.KeySetUrl, jwksBytes, time.Now())
+	}
 
 	return nil
 }
 
+// cacheRefreshDuration inspects the Cache-Control and Expires headers of a
+// JWKs response and returns how long to wait before refreshing again. It
+// returns 0 if the headers gave no guidance or asked not to be cached
+// (no-store). The result is clamped to [min, max] when those are set.
+func cacheRefreshDuration(header http.Header, min, max *time.Duration) time.Duration {
+	var d time.Duration
+	var ok bool
+
+	if cc := header.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.TrimSpace(directive)
+			if strings.EqualFold(directive, "no-store") {
+				return 0
+			}
+			if name, value, found := strings.Cut(directive, "="); found && strings.EqualFold(strings.TrimSpace(name), "max-age") {
+				if seconds, parseErr := strconv.Atoi(strings.TrimSpace(value)); parseErr == nil {
+					d = time.Duration(seconds) * time.Second
+					ok = true
+				}
+			}
+		}
+	}
+
+	if !ok {
+		if exp := header.Get("Expires"); exp != "" {
+			if t, parseErr := http.ParseTime(exp); parseErr == nil {
+				if until := time.Until(t); until > 0 {
+					d = until
+					ok = true
+				}
+			}
+		}
+	}
+
+	if !ok {
+		return 0
+	}
+
+	if min != nil && d < *min {
+		d = *min
+	}
+	if max != nil && d > *max {
+		d = *max
+	}
+
+	return d
+}
+
 // stopRefreshing ends the background goroutine to update the JWKs. It can only happen once and is only effective if the
 // JWKs has a background goroutine refreshing the JWKs keys.
 func (j *keySet) stopRefreshing() {
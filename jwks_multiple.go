@@ -0,0 +1,109 @@
+package jwtware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// KeySelector is a callback that lets a caller pick which named key set a
+// token should be resolved against, based on the issuer, audience, or any
+// other claim inside the token. Returning an empty string tells the
+// MultipleKeySet to fall back to searching every registered key set for the
+// kid.
+type KeySelector func(token *jwt.Token) (name string, err error)
+
+// ErrUnknownIssuer indicates that a key set name, whether returned by a
+// KeySelector or passed to Refresh, matches none of the registered key sets.
+var ErrUnknownIssuer = fmt.Errorf("could not determine which key set to use for this JWT")
+
+// MultipleKeySet aggregates several named keySets, such as one per IdP, so a
+// single jwtware middleware can validate JWTs issued by any of them.
+type MultipleKeySet struct {
+	keySets  map[string]*keySet
+	selector KeySelector
+}
+
+// GetMultiple creates a MultipleKeySet from a map of name to Config. The name
+// is only used to identify the key set to the KeySelector; it is not sent to
+// the IdP. If selector is nil, every registered key set is searched in an
+// unspecified order until one of them has the kid.
+func GetMultiple(configs map[string]Config, selector KeySelector) (*MultipleKeySet, error) {
+	keySets := make(map[string]*keySet, len(configs))
+	for name, config := range configs {
+		ks, err := getKeySet(config)
+		if err != nil {
+			// Stop every key set started by an earlier iteration so their
+			// background refresh goroutines don't leak.
+			for _, started := range keySets {
+				started.stopRefreshing()
+			}
+			return nil, fmt.Errorf("failed to load JWKs for %q: %w", name, err)
+		}
+		keySets[name] = ks
+	}
+
+	return &MultipleKeySet{
+		keySets:  keySets,
+		selector: selector,
+	}, nil
+}
+
+// Keyfunc is a compatibility function that matches the signature required by
+// github.com/golang-jwt/jwt. If a KeySelector was given and returns a
+// non-empty name, the token is resolved against that keySet alone, and a name
+// that matches no registered keySet yields ErrUnknownIssuer; an error from
+// the selector is returned as is. If there is no selector or it returns an
+// empty name, it walks every registered keySet looking for the kid.
+func (m *MultipleKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if m.selector != nil {
+		name, err := m.selector(token)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			ks, ok := m.keySets[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: selector returned unknown key set %q", ErrUnknownIssuer, name)
+			}
+			return ks.keyFunc(token)
+		}
+	}
+
+	var lastErr error
+	for _, ks := range m.keySets {
+		key, err := ks.keyFunc(token)
+		if err == nil {
+			return key, nil
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = ErrKIDNotFound
+	}
+	return nil, lastErr
+}
+
+// Refresh triggers an out-of-band refetch of the named key set's JWKs. Since
+// keySet is unexported, this is the only way an external caller can reach
+// (*keySet).Refresh for a key set obtained through GetMultiple. See
+// (*keySet).Refresh for the available options and error cases.
+func (m *MultipleKeySet) Refresh(ctx context.Context, name string, opts ...RefreshOption) error {
+	ks, ok := m.keySets[name]
+	if !ok {
+		return fmt.Errorf("%w: no key set named %q", ErrUnknownIssuer, name)
+	}
+
+	return ks.Refresh(ctx, opts...)
+}
+
+// stopRefreshing ends the background refresh goroutine of every registered
+// keySet. It can only happen once per keySet and is only effective if the
+// keySet has a background goroutine refreshing its keys.
+func (m *MultipleKeySet) stopRefreshing() {
+	for _, ks := range m.keySets {
+		ks.stopRefreshing()
+	}
+}
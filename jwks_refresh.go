@@ -0,0 +1,109 @@
+package jwtware
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRefreshImpossible indicates that Refresh was called on a key set that
+// was constructed from a static JSON document (via parseKeySet) rather than
+// fetched from a URL, so there is nothing to refetch.
+var ErrRefreshImpossible = errors.New("this key set was created from a static JSON document and has no URL to refresh from")
+
+// RefreshOptions holds the settings applied by RefreshOption functions.
+type RefreshOptions struct {
+	ignoreRateLimit    bool
+	blockUntilComplete bool
+}
+
+// RefreshOption configures a call to (*keySet).Refresh.
+type RefreshOption func(*RefreshOptions)
+
+// WithIgnoreRateLimit bypasses KeyRefreshRateLimit for this one refresh,
+// fetching the JWKs immediately instead of queueing it behind the background
+// goroutine's rate limiter.
+func WithIgnoreRateLimit() RefreshOption {
+	return func(o *RefreshOptions) {
+		o.ignoreRateLimit = true
+	}
+}
+
+// WithBlockUntilComplete makes Refresh wait for the refresh to finish before
+// returning, instead of only queueing it for the background goroutine and
+// returning immediately. An error from a refresh run by the background
+// goroutine goes to KeyRefreshErrorHandler, and Refresh returns nil once that
+// refresh is done; only a refresh made directly, as with WithIgnoreRateLimit,
+// returns its error to the caller.
+func WithBlockUntilComplete() RefreshOption {
+	return func(o *RefreshOptions) {
+		o.blockUntilComplete = true
+	}
+}
+
+// Refresh triggers an out-of-band refetch of the JWKs, for example after
+// receiving a webhook from the IdP reporting a key rotation, or after
+// deploying a new signing key. By default the refresh is queued for the
+// background goroutine and is subject to the same KeyRefreshRateLimit as an
+// unknown-kid refresh; use WithIgnoreRateLimit and WithBlockUntilComplete to
+// change that. It returns ErrRefreshImpossible if the key set was built from
+// a static JSON blob rather than a URL.
+func (j *keySet) Refresh(ctx context.Context, opts ...RefreshOption) error {
+	if j.config == nil || j.config.KeySetUrl == "" {
+		return ErrRefreshImpossible
+	}
+
+	options := &RefreshOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// Bypassing the rate limit, or there's no background goroutine to
+	// coordinate with: refresh directly.
+	if options.ignoreRateLimit || j.refreshRequests == nil {
+		if err := j.refresh(); err != nil {
+			return err
+		}
+		j.resetBackoff()
+		return nil
+	}
+
+	// req.done is only closed once the background goroutine has actually
+	// performed the fetch, even if that's delayed by rate limiting, so it's
+	// the signal WithBlockUntilComplete waits on. release has nothing to do
+	// here since nothing is waiting on the keyFunc fast path.
+	req := &refreshRequest{release: func() {}, done: make(chan struct{})}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-j.ctx.Done():
+		return j.ctx.Err()
+	case j.refreshRequests <- req:
+	default:
+		if !options.blockUntilComplete {
+			// The queue is already full; a refresh is effectively in
+			// flight, so there's nothing more to do.
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-j.ctx.Done():
+			return j.ctx.Err()
+		case j.refreshRequests <- req:
+		}
+	}
+
+	if options.blockUntilComplete {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-j.ctx.Done():
+			return j.ctx.Err()
+		case <-req.done:
+		}
+	}
+
+	return nil
+}